@@ -0,0 +1,165 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry is one cached rendering, keyed by the hash of the
+// []BarcodeData that produced it.
+type cacheEntry struct {
+	key       string
+	data      []byte
+	size      int
+	expiresAt time.Time
+}
+
+// ImageCache is a bounded, TTL-expiring LRU of rendered barcode PNGs, so
+// identical repeated form submissions short-circuit rendering entirely
+// instead of writing a new temp file every time.
+type ImageCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List // front = most recently used
+	maxEntries int
+	maxBytes   int
+	usedBytes  int
+	ttl        time.Duration
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewImageCache creates a cache that holds at most maxEntries items and
+// maxBytes of total PNG data, evicting least-recently-used entries first.
+// Entries also expire after ttl regardless of use.
+func NewImageCache(maxEntries int, maxBytes int, ttl time.Duration) *ImageCache {
+	return &ImageCache{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+	}
+}
+
+// Get returns the cached PNG bytes for key, if present and not expired.
+func (c *ImageCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeLocked(elem)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	atomic.AddUint64(&c.hits, 1)
+	return entry.data, true
+}
+
+// Put stores data under key, evicting older entries as needed to stay
+// within maxEntries and maxBytes.
+func (c *ImageCache) Put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+
+	entry := &cacheEntry{
+		key:       key,
+		data:      data,
+		size:      len(data),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	elem := c.order.PushFront(entry)
+	c.entries[key] = elem
+	c.usedBytes += entry.size
+
+	for (c.order.Len() > c.maxEntries || c.usedBytes > c.maxBytes) && c.order.Len() > 0 {
+		c.evictOldestLocked()
+	}
+}
+
+// evictOldestLocked drops the least-recently-used entry. Callers must hold c.mu.
+func (c *ImageCache) evictOldestLocked() {
+	elem := c.order.Back()
+	if elem == nil {
+		return
+	}
+	c.removeLocked(elem)
+	atomic.AddUint64(&c.evictions, 1)
+}
+
+// removeLocked detaches elem from both the list and the key index. Callers
+// must hold c.mu.
+func (c *ImageCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.usedBytes -= entry.size
+}
+
+// removeExpired drops every entry past its TTL. Called periodically by
+// runJanitor.
+func (c *ImageCache) removeExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*cacheEntry).expiresAt) {
+			c.removeLocked(elem)
+			atomic.AddUint64(&c.evictions, 1)
+		}
+		elem = prev
+	}
+}
+
+// runJanitor periodically sweeps expired entries until stop is closed.
+func (c *ImageCache) runJanitor(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.removeExpired()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Metrics reports cumulative hit/miss/eviction counters.
+func (c *ImageCache) Metrics() (hits, misses, evictions uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses), atomic.LoadUint64(&c.evictions)
+}
+
+// hashBarcodes returns a stable hex-encoded SHA-256 hash of barcodes, used
+// as the cache key. JSON-marshaling a slice of a fixed struct is
+// deterministic (field order follows the struct definition), so identical
+// form submissions always hash the same way.
+func hashBarcodes(barcodes []BarcodeData) (string, error) {
+	canonical, err := json.Marshal(barcodes)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
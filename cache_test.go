@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHashBarcodesStableAndDistinct(t *testing.T) {
+	a := []BarcodeData{{Data: "123", Symbology: SymbologyCode128, Width: 200, Height: 80}}
+	b := []BarcodeData{{Data: "123", Symbology: SymbologyCode128, Width: 200, Height: 80}}
+	c := []BarcodeData{{Data: "456", Symbology: SymbologyCode128, Width: 200, Height: 80}}
+
+	hashA, err := hashBarcodes(a)
+	if err != nil {
+		t.Fatalf("hashBarcodes(a): %v", err)
+	}
+	hashB, err := hashBarcodes(b)
+	if err != nil {
+		t.Fatalf("hashBarcodes(b): %v", err)
+	}
+	if hashA != hashB {
+		t.Errorf("identical input hashed differently: %q vs %q", hashA, hashB)
+	}
+
+	hashC, err := hashBarcodes(c)
+	if err != nil {
+		t.Fatalf("hashBarcodes(c): %v", err)
+	}
+	if hashA == hashC {
+		t.Errorf("different input hashed the same: %q", hashA)
+	}
+}
+
+func TestImageCacheGetPut(t *testing.T) {
+	c := NewImageCache(10, 1<<20, time.Hour)
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("Get on empty cache returned ok=true")
+	}
+
+	c.Put("key", []byte("payload"))
+	data, ok := c.Get("key")
+	if !ok || string(data) != "payload" {
+		t.Fatalf("Get(%q) = %q, %v; want %q, true", "key", data, ok, "payload")
+	}
+
+	hits, misses, _ := c.Metrics()
+	if hits != 1 || misses != 1 {
+		t.Errorf("Metrics() hits=%d misses=%d; want 1, 1", hits, misses)
+	}
+}
+
+func TestImageCacheEvictsOnMaxEntries(t *testing.T) {
+	c := NewImageCache(2, 1<<20, time.Hour)
+
+	c.Put("a", []byte("1"))
+	c.Put("b", []byte("1"))
+	c.Put("c", []byte("1")) // should evict "a", the least recently used
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected \"a\" to be evicted once maxEntries was exceeded")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("expected \"b\" to still be cached")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected \"c\" to still be cached")
+	}
+
+	_, _, evictions := c.Metrics()
+	if evictions != 1 {
+		t.Errorf("Metrics() evictions=%d; want 1", evictions)
+	}
+}
+
+func TestImageCacheEvictsOnMaxBytes(t *testing.T) {
+	c := NewImageCache(10, 10, time.Hour)
+
+	c.Put("a", make([]byte, 6))
+	c.Put("b", make([]byte, 6)) // 12 bytes total exceeds maxBytes of 10, evict "a"
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected \"a\" to be evicted once maxBytes was exceeded")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("expected \"b\" to still be cached")
+	}
+}
+
+func TestImageCacheExpiresByTTL(t *testing.T) {
+	c := NewImageCache(10, 1<<20, time.Millisecond)
+
+	c.Put("key", []byte("payload"))
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("key"); ok {
+		t.Error("expected entry to have expired past its TTL")
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/boombuler/barcode"
+	"github.com/fogleman/gg"
+)
+
+// BlockType identifies what kind of content a label Block renders.
+type BlockType string
+
+const (
+	BlockText    BlockType = "text"
+	BlockBarcode BlockType = "barcode"
+	BlockSpacer  BlockType = "spacer"
+	BlockImage   BlockType = "image"
+)
+
+// Alignment anchors a block's content within its box.
+type Alignment string
+
+const (
+	AlignLeft   Alignment = "left"
+	AlignCenter Alignment = "center"
+	AlignRight  Alignment = "right"
+)
+
+// Block is one element of a label: a line of text, a barcode, blank
+// spacing, or an embedded image, stacked top-to-bottom with the rest of
+// the label's blocks.
+type Block struct {
+	Type     BlockType `json:"type"`
+	Align    Alignment `json:"align"`
+	Rotation float64   `json:"rotation"` // degrees, clockwise
+	Height   int       `json:"height"`   // 0 means auto-size to remaining space
+
+	// BlockText fields.
+	Text       string  `json:"text"`
+	FontChoice string  `json:"font_choice"`
+	FontSize   float64 `json:"font_size"` // 0 means auto-size to fit Height
+	Bold       bool    `json:"bold"`
+	Color      string  `json:"color"`
+
+	// BlockBarcode fields.
+	Data         string    `json:"data"`
+	Symbology    Symbology `json:"symbology"`
+	ECLevel      string    `json:"ec_level"`
+	PaddingColor string    `json:"padding_color"`
+
+	// BlockImage fields.
+	ImageBase64 string `json:"image_base64"`
+}
+
+// LabelSpec declaratively describes an entire label: its physical size and
+// an ordered list of blocks to stack vertically.
+type LabelSpec struct {
+	WidthMM  float64 `json:"width_mm"`
+	HeightMM float64 `json:"height_mm"`
+	DPI      int     `json:"dpi"`
+	Blocks   []Block `json:"blocks"`
+}
+
+// pixelSize converts the label's physical dimensions to a pixel canvas
+// size at the spec's DPI.
+func (s LabelSpec) pixelSize() (width, height int) {
+	width = int(s.WidthMM / 25.4 * float64(s.DPI))
+	height = int(s.HeightMM / 25.4 * float64(s.DPI))
+	return width, height
+}
+
+// composeLabel renders spec's blocks onto a single canvas sized from its
+// physical dimensions and DPI.
+func composeLabel(spec LabelSpec) (*gg.Context, error) {
+	width, height := spec.pixelSize()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("label size must be positive (got %gx%g mm at %d dpi)", spec.WidthMM, spec.HeightMM, spec.DPI)
+	}
+
+	dc := gg.NewContext(width, height)
+	dc.SetColor(color.White)
+	dc.Clear()
+
+	// A block with Height == 0 auto-sizes to whatever vertical space is
+	// left after every other block has claimed its space, mirroring how a
+	// label's caption line grows or shrinks to fill the remaining height.
+	fixedHeight := 0
+	autoBlocks := 0
+	for _, b := range spec.Blocks {
+		if b.Height > 0 {
+			fixedHeight += b.Height
+		} else {
+			autoBlocks++
+		}
+	}
+	remaining := height - fixedHeight
+	autoHeight := 0
+	if autoBlocks > 0 && remaining > 0 {
+		autoHeight = remaining / autoBlocks
+	}
+
+	y := 0
+	for _, b := range spec.Blocks {
+		blockHeight := b.Height
+		if blockHeight == 0 {
+			blockHeight = autoHeight
+		}
+
+		if err := drawBlock(dc, b, y, width, blockHeight); err != nil {
+			return nil, err
+		}
+		y += blockHeight
+	}
+
+	return dc, nil
+}
+
+// drawBlock renders a single block into the horizontal strip
+// [0, width) x [y, y+blockHeight), anchoring its content per b.Align.
+func drawBlock(dc *gg.Context, b Block, y, width, blockHeight int) error {
+	switch b.Type {
+	case BlockSpacer:
+		return nil
+
+	case BlockText:
+		col := color.Color(color.Black)
+		if b.Color != "" {
+			c, err := parseHexColor(b.Color)
+			if err != nil {
+				return fmt.Errorf("invalid text block color: %v", err)
+			}
+			col = c
+		}
+
+		fontSize := b.FontSize
+		if fontSize == 0 {
+			fontSize = autoSizeTextForBox(b.FontChoice, b.Text, width, blockHeight)
+		}
+
+		anchorX, ax := anchorFor(b.Align, width)
+		textY := float64(y) + float64(blockHeight)/2
+
+		dc.Push()
+		if b.Rotation != 0 {
+			dc.RotateAbout(gg.Radians(b.Rotation), anchorX, textY)
+		}
+		if err := drawBarcodeTextAnchored(dc, b.FontChoice, b.Bold, b.Text, anchorX, textY, ax, 0.5, col, fontSize); err != nil {
+			dc.Pop()
+			return err
+		}
+		dc.Pop()
+		return nil
+
+	case BlockBarcode:
+		paddingColor := color.Color(color.White)
+		if b.PaddingColor != "" {
+			c, err := parseHexColor(b.PaddingColor)
+			if err != nil {
+				return fmt.Errorf("invalid barcode block padding color: %v", err)
+			}
+			paddingColor = c
+		}
+
+		sym := b.Symbology
+		if sym == "" {
+			sym = SymbologyCode128
+		}
+		bar, err := encodeSymbology(sym, b.Data, b.ECLevel)
+		if err != nil {
+			return fmt.Errorf("failed to generate barcode block: %v", err)
+		}
+
+		barWidth := width
+		barHeight := blockHeight
+		if sym.is2D() {
+			if barWidth > barHeight {
+				barWidth = barHeight
+			} else {
+				barHeight = barWidth
+			}
+		}
+		scaledBar, err := barcode.Scale(bar, barWidth, barHeight)
+		if err != nil {
+			return fmt.Errorf("failed to scale barcode block: %v", err)
+		}
+
+		x := xForAlign(b.Align, width, barWidth)
+		dc.SetColor(paddingColor)
+		dc.DrawRectangle(0, float64(y), float64(width), float64(blockHeight))
+		dc.Fill()
+
+		dc.Push()
+		if b.Rotation != 0 {
+			dc.RotateAbout(gg.Radians(b.Rotation), float64(x)+float64(barWidth)/2, float64(y)+float64(barHeight)/2)
+		}
+		dc.DrawImage(scaledBar, x, y)
+		dc.Pop()
+		return nil
+
+	case BlockImage:
+		img, err := decodeBase64Image(b.ImageBase64)
+		if err != nil {
+			return fmt.Errorf("failed to decode image block: %v", err)
+		}
+		x := xForAlign(b.Align, width, img.Bounds().Dx())
+
+		dc.Push()
+		if b.Rotation != 0 {
+			dc.RotateAbout(gg.Radians(b.Rotation), float64(x)+float64(img.Bounds().Dx())/2, float64(y)+float64(img.Bounds().Dy())/2)
+		}
+		dc.DrawImage(img, x, y)
+		dc.Pop()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown block type %q", b.Type)
+	}
+}
+
+// decodeBase64Image decodes a base64-encoded PNG/JPEG image block.
+func decodeBase64Image(encoded string) (image.Image, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image data: %v", err)
+	}
+	return img, nil
+}
+
+// anchorFor returns the x coordinate and gg anchor fraction that should be
+// passed to DrawStringAnchored for the given alignment within a box of the
+// given width.
+func anchorFor(align Alignment, width int) (x float64, ax float64) {
+	switch align {
+	case AlignLeft:
+		return 0, 0
+	case AlignRight:
+		return float64(width), 1
+	default:
+		return float64(width) / 2, 0.5
+	}
+}
+
+// xForAlign returns the left-edge x coordinate for drawing an element of
+// elemWidth inside a box of the given width, per align.
+func xForAlign(align Alignment, width, elemWidth int) int {
+	switch align {
+	case AlignLeft:
+		return 0
+	case AlignRight:
+		return width - elemWidth
+	default:
+		return (width - elemWidth) / 2
+	}
+}
+
+// autoSizeTextForBox finds the largest font size that fits text within
+// maxWidth at the given maxHeight, the way a caption line shrinks or grows
+// to exactly fill the space left after the rest of a label is laid out.
+// It steps down one point at a time from maxHeight and stops at 4 even if
+// the text is still wider than maxWidth. For a BDF font or an unknown key it
+// returns maxHeight without measuring the text's width.
+func autoSizeTextForBox(fontChoice, text string, maxWidth, maxHeight int) float64 {
+	if maxHeight <= 0 {
+		maxHeight = 1
+	}
+	dc := gg.NewContext(1, 1)
+	font, ok := fontRegistry.Lookup(fontChoice)
+	if !ok || font.Format != FontFormatTTF {
+		return float64(maxHeight)
+	}
+
+	size := float64(maxHeight)
+	for size > 4 {
+		if err := dc.LoadFontFace(font.Path, size); err != nil {
+			break
+		}
+		w, _ := dc.MeasureString(text)
+		if w <= float64(maxWidth) {
+			break
+		}
+		size -= 1
+	}
+	return size
+}
+
+// handleCompose accepts a LabelSpec as a JSON body and returns the
+// rendered label as a PNG.
+func handleCompose(w http.ResponseWriter, r *http.Request) {
+	var spec LabelSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid label spec: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	dc, err := composeLabel(spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, dc.Image()); err != nil {
+		http.Error(w, "Failed to encode image", http.StatusInternalServerError)
+		return
+	}
+}
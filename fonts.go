@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fogleman/gg"
+	"janouch.name/sklad/bdf"
+)
+
+// previewText is rendered into each font's gallery thumbnail.
+const previewText = "Abc 123"
+
+// FontFormat distinguishes the two font kinds the registry can serve.
+type FontFormat string
+
+const (
+	FontFormatTTF FontFormat = "ttf"
+	FontFormatBDF FontFormat = "bdf"
+)
+
+// Font is one entry in the font registry: a TTF face loaded on demand by
+// gg, or a BDF bitmap font parsed eagerly at startup.
+type Font struct {
+	Key    string
+	Name   string
+	Path   string
+	Format FontFormat
+	bdf    *bdf.Font
+}
+
+// FontRegistry indexes every font found under static/fonts by a stable key,
+// so form submissions reference fonts by key instead of a raw filename.
+type FontRegistry struct {
+	mu      sync.RWMutex
+	byKey   map[string]*Font
+	ordered []string
+}
+
+// LoadFontRegistry scans dir for .ttf and .bdf files and builds a registry
+// keyed by lowercased base filename (without extension). BDF fonts are
+// parsed immediately since glyph rendering needs the bitmap data; TTF faces
+// are loaded lazily per-draw by gg, as before.
+func LoadFontRegistry(dir string) (*FontRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fonts directory: %v", err)
+	}
+
+	reg := &FontRegistry{byKey: make(map[string]*Font)}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		base := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		key := strings.ToLower(base)
+		path := filepath.Join(dir, e.Name())
+
+		font := &Font{Key: key, Name: base, Path: path}
+		switch ext {
+		case ".ttf":
+			font.Format = FontFormatTTF
+		case ".bdf":
+			font.Format = FontFormatBDF
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to open bdf font %s: %v", path, err)
+			}
+			parsed, err := bdf.NewFromBDF(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse bdf font %s: %v", path, err)
+			}
+			font.bdf = parsed
+		default:
+			continue
+		}
+
+		reg.byKey[key] = font
+		reg.ordered = append(reg.ordered, key)
+	}
+
+	sort.Slice(reg.ordered, func(i, j int) bool {
+		return reg.byKey[reg.ordered[i]].Name < reg.byKey[reg.ordered[j]].Name
+	})
+	return reg, nil
+}
+
+// Lookup returns the font registered under key.
+func (r *FontRegistry) Lookup(key string) (*Font, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.byKey[key]
+	return f, ok
+}
+
+// List returns every registered font, sorted by display name.
+func (r *FontRegistry) List() []*Font {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fonts := make([]*Font, 0, len(r.ordered))
+	for _, key := range r.ordered {
+		fonts = append(fonts, r.byKey[key])
+	}
+	return fonts
+}
+
+// Preview renders a small sample of this font into a PNG, for the /fonts
+// gallery page.
+func (f *Font) Preview(size float64) ([]byte, error) {
+	dc := gg.NewContext(160, 40)
+	dc.SetColor(color.White)
+	dc.Clear()
+	dc.SetColor(color.Black)
+
+	switch f.Format {
+	case FontFormatBDF:
+		if err := drawBDFString(dc, f.bdf, previewText, 4, 12, color.Black, 2); err != nil {
+			return nil, err
+		}
+	default:
+		if err := dc.LoadFontFace(f.Path, size); err != nil {
+			return nil, fmt.Errorf("failed to load font face: %v", err)
+		}
+		dc.DrawStringAnchored(previewText, 8, 20, 0, 0.5)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dc.Image()); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawBDFString draws text using a BDF bitmap font directly onto dc,
+// anchored at (x, y) with the text vertically centered and left-aligned.
+func drawBDFString(dc *gg.Context, f *bdf.Font, text string, x, y float64, col color.Color, scale int) error {
+	return drawBDFStringAnchored(dc, f, text, x, y, 0, 0.5, col, scale)
+}
+
+// drawBDFStringAnchored is drawBDFString with an explicit (ax, ay) anchor
+// fraction, matching gg.Context.DrawStringAnchored's convention, so BDF
+// text can be left/right/center aligned the same way TTF text is.
+func drawBDFStringAnchored(dc *gg.Context, f *bdf.Font, text string, x, y, ax, ay float64, col color.Color, scale int) error {
+	if f == nil {
+		return fmt.Errorf("bdf font not loaded")
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	if text == "" {
+		return nil
+	}
+
+	// Render at the font's native pixel size first, using f.DrawString
+	// against an RGBA canvas sized to exactly fit the text's bounds, then
+	// scale the whole bitmap up by an integer factor, copying each native
+	// pixel into a scale x scale block, so pixels stay crisp instead of
+	// being blurred by a resampling filter.
+	bounds, advance := f.BoundString(text)
+	native := image.NewRGBA(image.Rect(0, 0, advance, bounds.Dy()))
+	f.DrawString(native, image.Pt(0, -bounds.Min.Y), col, text)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, native.Bounds().Dx()*scale, native.Bounds().Dy()*scale))
+	for sy := scaled.Bounds().Min.Y; sy < scaled.Bounds().Max.Y; sy++ {
+		for sx := scaled.Bounds().Min.X; sx < scaled.Bounds().Max.X; sx++ {
+			scaled.Set(sx, sy, native.At(sx/scale, sy/scale))
+		}
+	}
+
+	drawX := int(x) - int(ax*float64(scaled.Bounds().Dx()))
+	drawY := int(y) - int(ay*float64(scaled.Bounds().Dy()))
+	dc.DrawImage(scaled, drawX, drawY)
+	return nil
+}
+
+// serveFontGallery renders the /fonts page listing every registered font
+// alongside a small rendered preview, so users can pick a FontChoice key.
+func serveFontGallery(reg *FontRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.ParseFS(content, "templates/fonts.html")
+		if err != nil {
+			http.Error(w, "Error parsing template", http.StatusInternalServerError)
+			return
+		}
+		tmpl.Execute(w, struct{ Fonts []*Font }{Fonts: reg.List()})
+	}
+}
+
+// serveFontPreview serves the rendered preview PNG for a single font key.
+func serveFontPreview(reg *FontRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		font, ok := reg.Lookup(key)
+		if !ok {
+			http.Error(w, "Unknown font", http.StatusNotFound)
+			return
+		}
+		png, err := font.Preview(18)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to render preview: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}
+}
@@ -1,19 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"image"
 	"image/color"
 	"image/png"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/code128"
 	"github.com/fogleman/gg"
 )
 
@@ -24,35 +25,60 @@ var content embed.FS
 
 const baseDPI = 96
 
-// Load font from static fonts directory dynamically based on working directory
-func loadFontFromStatic(dc *gg.Context, fontName string, size float64) error {
-	// Get the current working directory
-	currentDir, err := os.Getwd() // Get the working directory
-	if err != nil {
-		return fmt.Errorf("unable to get current directory: %v", err)
+// fontRegistry indexes every font under static/fonts, built once at startup.
+var fontRegistry *FontRegistry
+
+// imageCache holds rendered barcode PNGs keyed by a hash of their input, so
+// repeated form submissions skip rendering entirely.
+var imageCache = NewImageCache(500, 64<<20, 10*time.Minute)
+
+// drawBarcodeText loads the font referenced by choice (a FontRegistry key)
+// and draws text centered on (x, y), dispatching to the BDF bitmap renderer
+// or gg's TTF face loader depending on the font's format.
+func drawBarcodeText(dc *gg.Context, choice string, bold bool, text string, x, y float64, col color.Color, size float64) error {
+	return drawBarcodeTextAnchored(dc, choice, bold, text, x, y, 0.5, 0.5, col, size)
+}
+
+// drawBarcodeTextAnchored is drawBarcodeText with an explicit anchor, so
+// callers that need left/right alignment (rather than always centering)
+// can position text relative to (x, y) accordingly.
+func drawBarcodeTextAnchored(dc *gg.Context, choice string, bold bool, text string, x, y, ax, ay float64, col color.Color, size float64) error {
+	if bold {
+		if _, ok := fontRegistry.Lookup(choice + "-bold"); ok {
+			choice = choice + "-bold"
+		}
 	}
 
-	// Construct the absolute path to the fonts directory
-	fontPath := filepath.Join(currentDir, "static", "fonts", fontName)
-	fmt.Println("Trying to load font from:", fontPath) // Log the file path for debugging
+	font, ok := fontRegistry.Lookup(choice)
+	if !ok {
+		return fmt.Errorf("unknown font choice: %s", choice)
+	}
 
-	// Check if the font file exists
-	if _, err := os.Stat(fontPath); os.IsNotExist(err) {
-		return fmt.Errorf("font not found at: %s", fontPath)
+	if font.Format == FontFormatBDF {
+		nativeHeight := font.bdf.Ascent + font.bdf.Descent
+		if nativeHeight < 1 {
+			nativeHeight = 1
+		}
+		scale := int(size) / nativeHeight
+		if scale < 1 {
+			scale = 1
+		}
+		return drawBDFStringAnchored(dc, font.bdf, text, x, y, ax, ay, col, scale)
 	}
 
-	// Load the font face
-	err = dc.LoadFontFace(fontPath, size)
-	if err != nil {
+	if err := dc.LoadFontFace(font.Path, size); err != nil {
 		return fmt.Errorf("failed to load font face: %v", err)
 	}
-
+	dc.SetColor(col)
+	dc.DrawStringAnchored(text, x, y, ax, ay)
 	return nil
 }
 
 // BarcodeData holds the properties for each barcode
 type BarcodeData struct {
 	Data         string
+	Symbology    Symbology
+	ECLevel      string
 	Width        int
 	Height       int
 	PaddingColor string
@@ -71,8 +97,10 @@ func parseHexColor(s string) (color.RGBA, error) {
 	return color.RGBA{uint8(c >> 16), uint8(c >> 8 & 0xFF), uint8(c & 0xFF), 0xFF}, nil
 }
 
-// Handle barcode generation
-func generateBarcode(w http.ResponseWriter, r *http.Request) {
+// parseBarcodesForm reads the up-to-4 barcode inputs out of a submitted
+// form. It's shared by the PNG (/barcode) and printer (/print) handlers,
+// which both compose the same canvas from the same fields.
+func parseBarcodesForm(r *http.Request) ([]BarcodeData, error) {
 	r.ParseForm()
 
 	var barcodes []BarcodeData
@@ -92,8 +120,16 @@ func generateBarcode(w http.ResponseWriter, r *http.Request) {
 		textSize, _ := strconv.Atoi(r.FormValue(fmt.Sprintf("text_size%d", i)))
 		bold := r.FormValue(fmt.Sprintf("bold%d", i)) == "on"
 
+		symbology := Symbology(r.FormValue(fmt.Sprintf("symbology%d", i)))
+		if symbology == "" {
+			symbology = SymbologyCode128
+		}
+		ecLevel := r.FormValue(fmt.Sprintf("ec_level%d", i))
+
 		barcodes = append(barcodes, BarcodeData{
 			Data:         data,
+			Symbology:    symbology,
+			ECLevel:      ecLevel,
 			Width:        width,
 			Height:       height,
 			PaddingColor: paddingColor,
@@ -104,19 +140,39 @@ func generateBarcode(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Validate if any barcodes were added
 	if len(barcodes) == 0 {
-		http.Error(w, "No barcode data provided", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("no barcode data provided")
 	}
+	return barcodes, nil
+}
 
+// scaledBarcodeDims returns the pixel dimensions b's barcode is scaled to
+// before compositing, squaring 2D symbologies onto their width rather than
+// stretching them to the requested height. This intentionally squares onto
+// width unconditionally, unlike compose.go's drawBlock (which squares onto
+// whichever of its box's width/height is smaller) — the two lay barcodes
+// out in different contexts and aren't meant to match pixel-for-pixel.
+func scaledBarcodeDims(b BarcodeData) (width, height int) {
+	width = b.Width * baseDPI / 96
+	height = b.Height * baseDPI / 96
+	if b.Symbology.is2D() {
+		height = width
+	}
+	return width, height
+}
+
+// composeBarcodeImage lays barcodes out side-by-side onto a single canvas
+// and returns the result, so callers can either PNG-encode it for the web
+// response or hand it to the printer backend.
+func composeBarcodeImage(barcodes []BarcodeData) (image.Image, error) {
 	// Calculate total canvas size
 	totalWidth := 0
 	totalHeight := 0
-	for _, barcode := range barcodes {
-		totalWidth += barcode.Width + (barcode.TextSize * 2)
-		if barcode.Height+(barcode.TextSize*3) > totalHeight {
-			totalHeight = barcode.Height + (barcode.TextSize * 3)
+	for _, b := range barcodes {
+		totalWidth += b.Width + (b.TextSize * 2)
+		_, barHeightAtDPI := scaledBarcodeDims(b)
+		if barHeightAtDPI+(b.TextSize*3) > totalHeight {
+			totalHeight = barHeightAtDPI + (b.TextSize * 3)
 		}
 	}
 
@@ -128,28 +184,23 @@ func generateBarcode(w http.ResponseWriter, r *http.Request) {
 		// Parse padding and text colors
 		paddingColor, err := parseHexColor(b.PaddingColor)
 		if err != nil {
-			http.Error(w, "Invalid padding color", http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("invalid padding color: %v", err)
 		}
 		textColor, err := parseHexColor(b.TextColor)
 		if err != nil {
-			http.Error(w, "Invalid text color", http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("invalid text color: %v", err)
 		}
 
 		// Generate barcode
-		bar, err := code128.Encode(b.Data)
+		bar, err := encodeSymbology(b.Symbology, b.Data, b.ECLevel)
 		if err != nil {
-			http.Error(w, "Failed to generate barcode", http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("failed to generate barcode: %v", err)
 		}
 
-		widthAtDPI := b.Width * baseDPI / 96
-		heightAtDPI := b.Height * baseDPI / 96
+		widthAtDPI, heightAtDPI := scaledBarcodeDims(b)
 		scaledBar, err := barcode.Scale(bar, widthAtDPI, heightAtDPI)
 		if err != nil {
-			http.Error(w, "Failed to scale barcode", http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("failed to scale barcode: %v", err)
 		}
 
 		// Draw background and barcode image
@@ -158,43 +209,48 @@ func generateBarcode(w http.ResponseWriter, r *http.Request) {
 		dc.Fill()
 		dc.DrawImage(scaledBar, xOffset+b.TextSize, b.TextSize)
 
-		// Draw the barcode text
-		dc.SetColor(textColor)
-
-		// Load appropriate font from static fonts
-		fontFile := "ARIAL.TTF" // Regular Arial
-		if b.Bold {
-			fontFile = "ARIBLK.TTF" // Arial Black
-		}
-
-		if err := loadFontFromStatic(dc, fontFile, float64(b.TextSize)); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to load font from static directory: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		// Draw the barcode text
+		// Draw the barcode text using the chosen registry font (TTF or BDF)
 		textX := float64(xOffset + b.TextSize + (b.Width / 2))
 		textY := float64(b.TextSize + b.Height + b.TextSize)
-		dc.DrawStringAnchored(b.Data, textX, textY, 0.5, 0.5)
+		if err := drawBarcodeText(dc, b.FontChoice, b.Bold, b.Data, textX, textY, textColor, float64(b.TextSize)); err != nil {
+			return nil, fmt.Errorf("failed to load font: %v", err)
+		}
 
 		xOffset += b.Width + (b.TextSize * 2)
 	}
 
-	// Save barcode image to temp folder with a unique name using timestamp
-	tempDir := os.TempDir()
-	fileName := fmt.Sprintf("generated_barcode_%d.png", time.Now().UnixNano())
-	filePath := filepath.Join(tempDir, fileName)
-	outFile, err := os.Create(filePath)
+	return dc.Image(), nil
+}
+
+// Handle barcode generation
+func generateBarcode(w http.ResponseWriter, r *http.Request) {
+	barcodes, err := parseBarcodesForm(r)
 	if err != nil {
-		http.Error(w, "Failed to save barcode", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer outFile.Close()
-	if err := png.Encode(outFile, dc.Image()); err != nil {
-		http.Error(w, "Failed to encode image", http.StatusInternalServerError)
+
+	hash, err := hashBarcodes(barcodes)
+	if err != nil {
+		http.Error(w, "Failed to hash barcode input", http.StatusInternalServerError)
 		return
 	}
 
+	if _, ok := imageCache.Get(hash); !ok {
+		img, err := composeBarcodeImage(barcodes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			http.Error(w, "Failed to encode image", http.StatusInternalServerError)
+			return
+		}
+		imageCache.Put(hash, buf.Bytes())
+	}
+
 	// Serve the generated barcode in the generated_barcode.html page
 	tmpl, err := template.ParseFS(content, "templates/generated_barcode.html")
 	if err != nil {
@@ -202,8 +258,8 @@ func generateBarcode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Pass the local server path to the template for rendering
-	barcodeURL := "/barcode_image?file=" + fileName
+	// Pass the cache lookup path to the template for rendering
+	barcodeURL := "/barcode_image?hash=" + hash
 	tmpl.Execute(w, struct{ BarcodePath string }{BarcodePath: barcodeURL})
 }
 
@@ -217,27 +273,57 @@ func serveForm(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, nil)
 }
 
-// Serve the generated barcode image from the temp folder
+// Serve a previously rendered barcode PNG out of the in-memory cache.
 func serveBarcodeImage(w http.ResponseWriter, r *http.Request) {
-	fileName := r.URL.Query().Get("file")
-	if fileName == "" {
-		http.Error(w, "File not specified", http.StatusBadRequest)
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		http.Error(w, "Hash not specified", http.StatusBadRequest)
 		return
 	}
 
-	// Get the full path of the barcode in the temp folder
-	filePath := filepath.Join(os.TempDir(), fileName)
+	data, ok := imageCache.Get(hash)
+	if !ok {
+		http.Error(w, "Barcode image not found or expired", http.StatusNotFound)
+		return
+	}
 
-	// Serve the file
-	http.ServeFile(w, r, filePath)
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+// serveCacheMetrics reports the image cache's cumulative hit/miss/eviction
+// counters as JSON.
+func serveCacheMetrics(w http.ResponseWriter, r *http.Request) {
+	hits, misses, evictions := imageCache.Metrics()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Hits      uint64 `json:"hits"`
+		Misses    uint64 `json:"misses"`
+		Evictions uint64 `json:"evictions"`
+	}{hits, misses, evictions})
 }
 
 func main() {
+	reg, err := LoadFontRegistry("static/fonts")
+	if err != nil {
+		fmt.Println("Failed to load font registry:", err)
+		os.Exit(1)
+	}
+	fontRegistry = reg
+
+	go imageCache.runJanitor(time.Minute, make(chan struct{}))
+
 	// Serve static files
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
 	http.HandleFunc("/", serveForm)
 	http.HandleFunc("/barcode", generateBarcode)
 	http.HandleFunc("/barcode_image", serveBarcodeImage)
+	http.HandleFunc("/fonts", serveFontGallery(fontRegistry))
+	http.HandleFunc("/fonts/preview", serveFontPreview(fontRegistry))
+	http.HandleFunc("/print", handlePrint)
+	http.HandleFunc("/status", handlePrinterStatus)
+	http.HandleFunc("/metrics", serveCacheMetrics)
+	http.HandleFunc("/compose", handleCompose)
 
 	fmt.Println("FCS Barcode Generator is Alive! Navigate to http://localhost:8080 to Generate your barcode..;) b.rad.year.2070@gmail.com")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"barcode/printer"
+)
+
+// printerDevicePath is the USB printer-class device node the /print and
+// /status endpoints talk to. Overridable via the PRINTER_DEVICE env var so
+// the same binary can be pointed at a different Brother unit without a
+// rebuild.
+var printerDevicePath = envOr("PRINTER_DEVICE", "/dev/usb/lp0")
+
+// printerModel is the connected printer's model, passed to printer.Open.
+// Raster line width and status decoding do not depend on it.
+var printerModel = printer.ModelQL800
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// handlePrint composes the submitted barcodes exactly as /barcode does,
+// but instead of returning a PNG it rotates the canvas 90 degrees so wide
+// barcodes fit continuous-length tape and streams it to the Brother
+// printer as a raster job.
+func handlePrint(w http.ResponseWriter, r *http.Request) {
+	barcodes, err := parseBarcodesForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := composeBarcodeImage(barcodes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p, err := printer.Open(printerDevicePath, printerModel)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to connect to printer: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer p.Close()
+
+	rotated := printer.RotateLeft90(img)
+	if err := p.Print(rotated); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to print: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "Print job sent")
+}
+
+// handlePrinterStatus decodes the printer's status response into
+// human-readable JSON.
+func handlePrinterStatus(w http.ResponseWriter, r *http.Request) {
+	p, err := printer.Open(printerDevicePath, printerModel)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to connect to printer: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer p.Close()
+
+	status, err := p.ReadStatus()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read printer status: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,181 @@
+// Package printer talks to Brother QL-series label printers over USB, by
+// writing raster commands directly to the OS's USB-printer-class device
+// node (e.g. /dev/usb/lp0 on Linux).
+//
+// PT-CBP (P-touch Cube) is not supported: it doesn't speak the QL raster
+// protocol implemented here, and would need its own command set.
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+)
+
+// Model identifies a supported Brother QL label printer. It is recorded on
+// the Printer; raster line width and status decoding are the same for every
+// model.
+type Model string
+
+const (
+	ModelQL800    Model = "QL-800"
+	ModelQL820NWB Model = "QL-820NWB"
+)
+
+// pixelsPerLine is the Brother QL print head's fixed raster line width, in
+// dots, at 300 DPI.
+const pixelsPerLine = 720
+
+// Printer is an open connection to a Brother label printer.
+type Printer struct {
+	dev   *os.File
+	Model Model
+}
+
+// Open connects to the printer exposed at devicePath, typically
+// /dev/usb/lp0 on Linux.
+func Open(devicePath string, model Model) (*Printer, error) {
+	dev, err := os.OpenFile(devicePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open printer device %s: %v", devicePath, err)
+	}
+	return &Printer{dev: dev, Model: model}, nil
+}
+
+// Close releases the underlying device handle.
+func (p *Printer) Close() error {
+	return p.dev.Close()
+}
+
+// RotateLeft90 rotates img 90 degrees counter-clockwise, so a wide barcode
+// canvas prints along the length of a continuous tape instead of across
+// its (fixed) width.
+func RotateLeft90(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rotated := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// (x, y) in the source maps to (y, width-1-x) in the rotated image.
+			rx := y - b.Min.Y
+			ry := b.Max.X - 1 - x
+			rotated.Set(rx, ry, img.At(x, y))
+		}
+	}
+	return rotated
+}
+
+// rasterLines converts img into Brother QL raster lines: one []byte of
+// packed 1-bpp pixels per row, each pixelsPerLine dots wide, black pixels
+// set to 1. Rows narrower than pixelsPerLine are left-padded with white so
+// the print head is centered on narrow tape.
+func rasterLines(img image.Image) [][]byte {
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, img.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	b := gray.Bounds()
+	lines := make([][]byte, 0, b.Dy())
+	offset := (pixelsPerLine - b.Dx()) / 2
+	if offset < 0 {
+		offset = 0
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		line := make([]byte, (pixelsPerLine+7)/8)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dot := offset + (x - b.Min.X)
+			if dot >= pixelsPerLine {
+				continue
+			}
+			if gray.GrayAt(x, y).Y < 128 {
+				line[dot/8] |= 0x80 >> uint(dot%8)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Print rasterizes img and streams it to the printer using the QL raster
+// command set: initialize, switch to raster mode, one "transfer raster
+// line" command per row, then a print command that feeds the page.
+func (p *Printer) Print(img image.Image) error {
+	var cmds []byte
+
+	// Initialize: clear any partially-sent command.
+	cmds = append(cmds, 0x1B, 0x40)
+	// Switch to raster mode.
+	cmds = append(cmds, 0x1B, 0x69, 0x61, 0x01)
+
+	for _, line := range rasterLines(img) {
+		cmds = append(cmds, 0x67, 0x00, byte(len(line)))
+		cmds = append(cmds, line...)
+	}
+
+	// Print, feeding the page at the end of the raster job.
+	cmds = append(cmds, 0x1A)
+
+	if _, err := p.dev.Write(cmds); err != nil {
+		return fmt.Errorf("failed to write raster job: %v", err)
+	}
+	return nil
+}
+
+// Status is the decoded form of a Brother QL status response.
+type Status struct {
+	MediaType    string
+	MediaWidthMM int
+	ErrorFlags   []string
+}
+
+var mediaTypes = map[byte]string{
+	0x00: "no media",
+	0x0A: "continuous length tape",
+	0x0B: "die-cut labels",
+}
+
+var errorBits = []struct {
+	byteIndex int
+	mask      byte
+	message   string
+}{
+	{8, 0x01, "no media"},
+	{8, 0x02, "end of media"},
+	{8, 0x04, "tape cutter jam"},
+	{8, 0x10, "main unit in use"},
+	{8, 0x40, "high-voltage adapter"},
+	{8, 0x80, "fan malfunction"},
+	{9, 0x01, "replace media"},
+	{9, 0x04, "communication error"},
+	{9, 0x10, "cover open"},
+	{9, 0x40, "media cannot be fed"},
+}
+
+// ReadStatus requests and decodes a 32-byte status response, which reports
+// the loaded media, its width, and any error flags.
+func (p *Printer) ReadStatus() (*Status, error) {
+	// Status information request.
+	if _, err := p.dev.Write([]byte{0x1B, 0x69, 0x53}); err != nil {
+		return nil, fmt.Errorf("failed to request status: %v", err)
+	}
+
+	// A single Read can return short on a USB character device, leaving the
+	// tail of buf zeroed; ReadFull keeps reading until buf is full or an
+	// error occurs.
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(p.dev, buf); err != nil {
+		return nil, fmt.Errorf("failed to read status: %v", err)
+	}
+
+	status := &Status{
+		MediaType:    mediaTypes[buf[11]],
+		MediaWidthMM: int(buf[10]),
+	}
+	for _, eb := range errorBits {
+		if buf[eb.byteIndex]&eb.mask != 0 {
+			status.ErrorFlags = append(status.ErrorFlags, eb.message)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
+	"github.com/boombuler/barcode/qr"
+)
+
+// Symbology identifies which barcode/2D-code format to encode a barcode's data as.
+type Symbology string
+
+const (
+	SymbologyCode128    Symbology = "code128"
+	SymbologyCode39     Symbology = "code39"
+	SymbologyEAN13      Symbology = "ean13"
+	SymbologyUPCA       Symbology = "upca"
+	SymbologyQR         Symbology = "qr"
+	SymbologyDataMatrix Symbology = "datamatrix"
+	SymbologyAztec      Symbology = "aztec"
+	SymbologyPDF417     Symbology = "pdf417"
+)
+
+// is2D reports whether a symbology produces a square 2D code rather than a
+// horizontal 1D strip, which changes how it should be laid out on the canvas.
+func (s Symbology) is2D() bool {
+	switch s {
+	case SymbologyQR, SymbologyDataMatrix, SymbologyAztec, SymbologyPDF417:
+		return true
+	default:
+		return false
+	}
+}
+
+var numericPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// validateSymbologyData checks that data is well-formed for the given
+// symbology before encoding is attempted, so callers can return a clear
+// validation error instead of a cryptic one from the underlying encoder.
+func validateSymbologyData(sym Symbology, data string) error {
+	switch sym {
+	case SymbologyEAN13:
+		if !numericPattern.MatchString(data) || len(data) != 13 {
+			return fmt.Errorf("ean13 data must be exactly 13 digits, got %q", data)
+		}
+	case SymbologyUPCA:
+		if !numericPattern.MatchString(data) || len(data) != 12 {
+			return fmt.Errorf("upca data must be exactly 12 digits, got %q", data)
+		}
+	case SymbologyCode39, SymbologyCode128, SymbologyQR, SymbologyDataMatrix, SymbologyAztec, SymbologyPDF417:
+		if data == "" {
+			return fmt.Errorf("%s data must not be empty", sym)
+		}
+	default:
+		return fmt.Errorf("unknown symbology %q", sym)
+	}
+	return nil
+}
+
+// qrECLevel maps the user-facing ec_level form value onto the qr package's
+// ErrorCorrectionLevel, defaulting to medium when unset or unrecognized.
+func qrECLevel(level string) qr.ErrorCorrectionLevel {
+	switch level {
+	case "L":
+		return qr.L
+	case "M":
+		return qr.M
+	case "Q":
+		return qr.Q
+	case "H":
+		return qr.H
+	default:
+		return qr.M
+	}
+}
+
+// encodeSymbology dispatches to the boombuler/barcode sub-package matching
+// sym and returns the resulting unscaled barcode.Barcode.
+func encodeSymbology(sym Symbology, data string, ecLevel string) (barcode.Barcode, error) {
+	if err := validateSymbologyData(sym, data); err != nil {
+		return nil, err
+	}
+
+	switch sym {
+	case SymbologyCode128:
+		return code128.Encode(data)
+	case SymbologyCode39:
+		return code39.Encode(data, false, true)
+	case SymbologyEAN13:
+		return ean.Encode(data)
+	case SymbologyUPCA:
+		// boombuler/barcode has no dedicated UPC-A encoder; UPC-A is EAN-13
+		// with a leading zero, so encode it as such.
+		return ean.Encode("0" + data)
+	case SymbologyQR:
+		return qr.Encode(data, qrECLevel(ecLevel), qr.Auto)
+	case SymbologyDataMatrix:
+		return datamatrix.Encode(data)
+	case SymbologyAztec:
+		return aztec.Encode([]byte(data), 0, 0)
+	case SymbologyPDF417:
+		return pdf417.Encode(data, 0)
+	default:
+		return nil, fmt.Errorf("unknown symbology %q", sym)
+	}
+}